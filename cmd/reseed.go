@@ -1,13 +1,21 @@
 package cmd
 
 import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base32"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/MDrollette/i2p-tools/reseed"
@@ -15,8 +23,17 @@ import (
 	"github.com/cretz/bine/tor"
 	"github.com/cretz/bine/torutil"
 	"github.com/cretz/bine/torutil/ed25519"
+	"github.com/eyedeekay/sam3"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/crypto/curve25519"
 )
 
+// acmeStagingURL is Let's Encrypt's staging directory, used to avoid
+// production rate limits while testing ACME configuration.
+const acmeStagingURL = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
 func NewReseedCommand() cli.Command {
 	return cli.Command{
 		Name:   "reseed",
@@ -35,6 +52,20 @@ func NewReseedCommand() cli.Command {
 				Name:  "onion",
 				Usage: "Present an onionv3 address",
 			},
+			cli.BoolFlag{
+				Name:  "i2p",
+				Usage: "Serve reseed traffic over an I2P streaming session via SAM",
+			},
+			cli.StringFlag{
+				Name:  "samAddr",
+				Value: "127.0.0.1:7656",
+				Usage: "Address of the SAM bridge to use for the I2P destination",
+			},
+			cli.StringFlag{
+				Name:  "i2pKey",
+				Value: "i2p.key",
+				Usage: "Specify a path to a persistent I2P destination private key",
+			},
 			cli.BoolFlag{
 				Name:  "singleOnion",
 				Usage: "Use a faster, but non-anonymous single-hop onion",
@@ -44,6 +75,10 @@ func NewReseedCommand() cli.Command {
 				Value: "onion.key",
 				Usage: "Specify a path to an ed25519 private key for onion",
 			},
+			cli.StringSliceFlag{
+				Name:  "onionClientAuth",
+				Usage: "Base32 x25519 public key (or path to a file of them, one per line) authorized to decrypt the onion descriptor. Repeatable; restricts the onion to these clients.",
+			},
 			cli.StringFlag{
 				Name:  "key",
 				Usage: "Path to your su3 signing private key",
@@ -60,6 +95,23 @@ func NewReseedCommand() cli.Command {
 				Name:  "tlsKey",
 				Usage: "Path to a TLS private key",
 			},
+			cli.BoolFlag{
+				Name:  "acme",
+				Usage: "Automatically provision a TLS certificate for tlsHost via ACME (Let's Encrypt)",
+			},
+			cli.StringFlag{
+				Name:  "acmeEmail",
+				Usage: "Contact email registered with the ACME account",
+			},
+			cli.StringFlag{
+				Name:  "acmeCacheDir",
+				Value: "acme-cache",
+				Usage: "Directory to cache ACME account and certificate data in",
+			},
+			cli.BoolFlag{
+				Name:  "acmeStaging",
+				Usage: "Use the ACME staging directory instead of production",
+			},
 			cli.StringFlag{
 				Name:  "ip",
 				Value: "0.0.0.0",
@@ -97,13 +149,28 @@ func NewReseedCommand() cli.Command {
 			cli.StringFlag{
 				Name:  "blacklist",
 				Value: "",
-				Usage: "Path to a txt file containing a list of IPs to deny connections from.",
+				Usage: "Path to a txt file containing a list of IPs or CIDR ranges to deny connections from. Reloaded automatically on change.",
+			},
+			cli.IntFlag{
+				Name:  "rateLimit",
+				Value: 0,
+				Usage: "Maximum requests per minute per IP before it's temporarily banned (0 = disabled)",
+			},
+			cli.DurationFlag{
+				Name:  "banDuration",
+				Value: 10 * time.Minute,
+				Usage: "How long an IP stays banned after exceeding --rateLimit",
 			},
 			cli.DurationFlag{
 				Name:  "stats",
 				Value: 0,
 				Usage: "Periodically print memory stats.",
 			},
+			cli.StringFlag{
+				Name:  "metricsAddr",
+				Value: "",
+				Usage: "Address to serve Prometheus metrics on (ex. 127.0.0.1:9100)",
+			},
 		},
 	}
 }
@@ -124,23 +191,44 @@ func reseedAction(c *cli.Context) {
 
 	var tlsCert, tlsKey string
 	tlsHost := c.String("tlsHost")
+	// ACME can only provision a cert for a hostname the operator actually
+	// controls DNS for, not one derived from --onion/--i2p below
+	explicitTLSHost := tlsHost != ""
+
+	var i2pKeys sam3.I2PKeys
+	if c.Bool("i2p") {
+		sam, err := sam3.NewSAM(c.String("samAddr"))
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		i2pKeys, err = sam.EnsureKeyfile(c.String("i2pKey"))
+		sam.Close()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if tlsHost == "" {
+			tlsHost = i2pKeys.Addr().Base32()
+		}
+	}
 
 	if c.Bool("onion") {
 		var ok []byte
 		var err error
-		if tlsHost == "" {
-			if _, err = os.Stat(c.String("onionKey")); err == nil {
-				ok, err = ioutil.ReadFile(c.String("onionKey"))
-				if err != nil {
-					log.Fatalln(err.Error())
-				}
-			} else {
-				key, err := ed25519.GenerateKey(nil)
-				if err != nil {
-					log.Fatalln(err.Error())
-				}
-				ok = []byte(key.PrivateKey())
+		if _, err = os.Stat(c.String("onionKey")); err == nil {
+			ok, err = ioutil.ReadFile(c.String("onionKey"))
+			if err != nil {
+				log.Fatalln(err.Error())
 			}
+		} else {
+			key, err := ed25519.GenerateKey(nil)
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			ok = []byte(key.PrivateKey())
+		}
+		// always persist, but only derive tlsHost from it if nothing else
+		// (e.g. --i2p or --tlsHost) has already claimed that name
+		if tlsHost == "" {
 			tlsHost = torutil.OnionServiceIDFromPrivateKey(ed25519.PrivateKey(ok)) + ".onion"
 		}
 		err = ioutil.WriteFile(c.String("onionKey"), ok, 0644)
@@ -149,7 +237,25 @@ func reseedAction(c *cli.Context) {
 		}
 	}
 
-	if tlsHost != "" {
+	var acmeManager *autocert.Manager
+	if explicitTLSHost && c.Bool("acme") {
+		acmeManager = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(tlsHost),
+			Cache:      autocert.DirCache(c.String("acmeCacheDir")),
+			Email:      c.String("acmeEmail"),
+		}
+		if c.Bool("acmeStaging") {
+			acmeManager.Client = &acme.Client{DirectoryURL: acmeStagingURL}
+		}
+
+		// ACME's HTTP-01 challenge must be answered on port 80, if available
+		go func() {
+			if err := http.ListenAndServe(":80", acmeManager.HTTPHandler(nil)); err != nil {
+				log.Printf("acme: could not bind :80 for HTTP-01 challenges, falling back to TLS-ALPN-01: %s", err)
+			}
+		}()
+	} else if tlsHost != "" {
 		tlsKey = c.String("tlsKey")
 		// if no key is specified, default to the host.pem in the current dir
 		if tlsKey == "" {
@@ -204,12 +310,20 @@ func reseedAction(c *cli.Context) {
 	server.Reseeder = reseeder
 	server.Addr = net.JoinHostPort(c.String("ip"), c.String("port"))
 
-	// load a blacklist
+	// load a blacklist of IPs and CIDR ranges; entries from the file never
+	// expire, only the rate limiter's auto-bans below carry a TTL
 	blacklist := reseed.NewBlacklist()
 	server.Blacklist = blacklist
 	blacklistFile := c.String("blacklist")
 	if "" != blacklistFile {
 		blacklist.LoadFile(blacklistFile)
+		blacklist.Watch(blacklistFile)
+	}
+
+	// auto-ban IPs (or X-Forwarded-For values, if --trustProxy) that exceed
+	// --rateLimit requests per minute for --banDuration
+	if rateLimit := c.Int("rateLimit"); rateLimit > 0 {
+		blacklist.SetRateLimit(rateLimit, c.Duration("banDuration"), c.Bool("trustProxy"))
 	}
 
 	// print stats once in a while
@@ -223,68 +337,234 @@ func reseedAction(c *cli.Context) {
 		}()
 	}
 
-	if c.Bool("onion") {
-		port, err := strconv.Atoi(c.String("port"))
+	// serve Prometheus metrics, alongside the --stats log output if enabled
+	if metricsAddr := c.String("metricsAddr"); metricsAddr != "" {
+		metrics := reseed.NewMetrics()
+		server.Metrics = metrics
+		reseeder.Metrics = metrics
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		go func() {
+			log.Printf("Metrics server started on %s\n", metricsAddr)
+			log.Fatalln(http.ListenAndServe(metricsAddr, mux))
+		}()
+	}
+
+	// certStore holds the TLS certificate currently in use
+	var certStore atomic.Value
+	if tlsCert != "" && tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
 		if err != nil {
 			log.Fatalln(err.Error())
 		}
-		if _, err := os.Stat(c.String("onionKey")); err == nil {
-			ok, err := ioutil.ReadFile(c.String("onionKey"))
-			if err != nil {
-				log.Fatalln(err.Error())
-			} else {
-				if tlsCert != "" && tlsKey != "" {
-					log.Fatalln(
-						server.ListenAndServeOnionTLS(
-							nil,
-							&tor.ListenConf{
-								LocalPort:    port,
-								Key:          ed25519.PrivateKey(ok),
-								RemotePorts:  []int{443},
-								Version3:     true,
-								NonAnonymous: c.Bool("singleOnion"),
-								DiscardKey:   false,
-							},
-							tlsCert, tlsKey,
-						),
-					)
+		certStore.Store(&cert)
+	}
+
+	// reload the TLS certificate and su3 signing key on SIGHUP. The reloaded
+	// certificate is used by the HTTPS and onion TLS listeners; the I2P TLS
+	// listener keeps the certificate it loaded from disk at start.
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if tlsCert != "" && tlsKey != "" {
+				if cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey); err != nil {
+					log.Printf("reload: failed to reload TLS certificate: %s", err)
 				} else {
-					log.Fatalln(
-						server.ListenAndServeOnion(
-							nil,
-							&tor.ListenConf{
-								LocalPort:    port,
-								Key:          ed25519.PrivateKey(ok),
-								RemotePorts:  []int{80},
-								Version3:     true,
-								NonAnonymous: c.Bool("singleOnion"),
-								DiscardKey:   false,
-							},
-						),
-					)
+					certStore.Store(&cert)
+					log.Println("reload: TLS certificate reloaded")
 				}
 			}
-		} else if os.IsNotExist(err) {
-			log.Fatalln(
-				server.ListenAndServeOnion(
-					nil,
-					&tor.ListenConf{
-						LocalPort:    port,
-						RemotePorts:  []int{80},
-						Version3:     true,
-						NonAnonymous: c.Bool("singleOnion"),
-						DiscardKey:   false,
-					},
-				),
+
+			if newKey, err := getOrNewSigningCert(&signerKey, signerID); err != nil {
+				log.Printf("reload: failed to reload su3 signing key: %s", err)
+			} else {
+				reseeder.SetSigningKey(newKey)
+				log.Println("reload: su3 signing key reloaded")
+			}
+		}
+	}()
+
+	reloadableTLSConfig := func() *tls.Config {
+		return &tls.Config{
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return certStore.Load().(*tls.Certificate), nil
+			},
+		}
+	}
+
+	// serveOnion publishes the onion service and blocks serving it. It
+	// doesn't call log.Fatalln itself so it can also be run in a goroutine
+	// alongside another transport (see the --onion+--i2p case below).
+	serveOnion := func() error {
+		port, err := strconv.Atoi(c.String("port"))
+		if err != nil {
+			return err
+		}
+		clientAuths, err := parseOnionClientAuths(c.StringSlice("onionClientAuth"))
+		if err != nil {
+			return err
+		}
+
+		ok, err := ioutil.ReadFile(c.String("onionKey"))
+		if err != nil {
+			return err
+		}
+
+		if tlsCert != "" && tlsKey != "" {
+			return server.ListenAndServeOnionTLSConfig(
+				nil,
+				&tor.ListenConf{
+					LocalPort:    port,
+					Key:          ed25519.PrivateKey(ok),
+					RemotePorts:  []int{443},
+					Version3:     true,
+					NonAnonymous: c.Bool("singleOnion"),
+					DiscardKey:   false,
+					ClientAuths:  clientAuths,
+				},
+				reloadableTLSConfig(),
 			)
-		} else {
+		}
+
+		return server.ListenAndServeOnion(
+			nil,
+			&tor.ListenConf{
+				LocalPort:    port,
+				Key:          ed25519.PrivateKey(ok),
+				RemotePorts:  []int{80},
+				Version3:     true,
+				NonAnonymous: c.Bool("singleOnion"),
+				DiscardKey:   false,
+				ClientAuths:  clientAuths,
+			},
+		)
+	}
 
+	// serveI2P publishes the I2P destination and blocks serving it.
+	serveI2P := func() error {
+		if tlsCert != "" && tlsKey != "" {
+			return server.ListenAndServeI2PTLS(c.String("samAddr"), i2pKeys, tlsCert, tlsKey)
 		}
-	} else if tlsHost != "" && tlsCert != "" && tlsKey != "" {
+		return server.ListenAndServeI2P(c.String("samAddr"), i2pKeys)
+	}
+
+	switch {
+	case c.Bool("onion") && c.Bool("i2p"):
+		// both transports are independent listeners, so run them side by
+		// side rather than letting one silently win
+		go func() {
+			if err := serveOnion(); err != nil {
+				log.Fatalln(err.Error())
+			}
+		}()
+		log.Printf("I2P destination: %s\n", i2pKeys.Addr().Base32())
+		log.Fatalln(serveI2P())
+	case c.Bool("onion"):
+		log.Fatalln(serveOnion())
+	case c.Bool("i2p"):
+		log.Printf("I2P destination: %s\n", i2pKeys.Addr().Base32())
+		log.Fatalln(serveI2P())
+	case tlsHost != "" && acmeManager != nil:
+		log.Printf("HTTPS server started on %s (ACME)\n", server.Addr)
+		log.Fatalln(server.ListenAndServeTLSConfig(acmeManager.TLSConfig()))
+	case tlsHost != "" && tlsCert != "" && tlsKey != "":
 		log.Printf("HTTPS server started on %s\n", server.Addr)
-		log.Fatalln(server.ListenAndServeTLS(tlsCert, tlsKey))
-	} else {
+		log.Fatalln(server.ListenAndServeTLSConfig(reloadableTLSConfig()))
+	default:
 		log.Printf("HTTP server started on %s\n", server.Addr)
 		log.Fatalln(server.ListenAndServe())
 	}
 }
+
+// parseOnionClientAuths turns --onionClientAuth values, each either a bare
+// base32 x25519 public key or a path to a file of one-per-line keys, into
+// client auth entries for tor.ListenConf.
+func parseOnionClientAuths(values []string) ([]*tor.ClientAuth, error) {
+	var lines []string
+	for _, v := range values {
+		if _, err := os.Stat(v); err == nil {
+			contents, err := ioutil.ReadFile(v)
+			if err != nil {
+				return nil, err
+			}
+			for _, line := range strings.Split(string(contents), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					lines = append(lines, line)
+				}
+			}
+		} else {
+			lines = append(lines, v)
+		}
+	}
+
+	var clientAuths []*tor.ClientAuth
+	for _, line := range lines {
+		pub, err := decodeOnionAuthPubKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --onionClientAuth key %q: %s", line, err)
+		}
+		clientAuths = append(clientAuths, &tor.ClientAuth{Key: pub})
+	}
+
+	return clientAuths, nil
+}
+
+// decodeOnionAuthPubKey decodes a "descriptor:x25519:BASE32" line or a bare
+// base32-encoded x25519 public key into its raw 32 bytes.
+func decodeOnionAuthPubKey(s string) ([]byte, error) {
+	if parts := strings.Split(s, ":"); len(parts) == 3 && parts[0] == "descriptor" && parts[1] == "x25519" {
+		s = parts[2]
+	}
+
+	pub, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(pub) != 32 {
+		return nil, fmt.Errorf("expected a 32-byte x25519 public key, got %d bytes", len(pub))
+	}
+
+	return pub, nil
+}
+
+func NewGenOnionAuthCommand() cli.Command {
+	return cli.Command{
+		Name:   "gen-onion-auth",
+		Usage:  "Generate an onion service client authorization keypair",
+		Action: genOnionAuthAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "out",
+				Value: "onion-auth.key",
+				Usage: "Path to write the generated private key to",
+			},
+		},
+	}
+}
+
+func genOnionAuthAction(c *cli.Context) {
+	var priv [32]byte
+	if _, err := rand.Read(priv[:]); err != nil {
+		log.Fatalln(err.Error())
+	}
+	// clamp per the curve25519 spec
+	priv[0] &= 248
+	priv[31] &= 127
+	priv[31] |= 64
+
+	pub, err := curve25519.X25519(priv[:], curve25519.Basepoint)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	out := c.String("out")
+	if err := ioutil.WriteFile(out, priv[:], 0600); err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	fmt.Printf("descriptor:x25519:%s\n", enc.EncodeToString(pub))
+	fmt.Printf("private key written to %s\n", out)
+}
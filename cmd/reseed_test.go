@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeOnionAuthPubKey(t *testing.T) {
+	// base32(no padding) of 32 zero bytes
+	zeroKey := "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"bare base32 key", zeroKey, false},
+		{"descriptor-prefixed key", "descriptor:x25519:" + zeroKey, false},
+		{"lowercase", "descriptor:x25519:" + strings.ToLower(zeroKey), false},
+		{"invalid base32", "not-valid-base32!!!", true},
+		{"wrong length", "AAAAAAAA", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub, err := decodeOnionAuthPubKey(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decodeOnionAuthPubKey(%q) = %x, nil; want error", tt.in, pub)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeOnionAuthPubKey(%q) returned error: %s", tt.in, err)
+			}
+			if len(pub) != 32 {
+				t.Fatalf("decodeOnionAuthPubKey(%q) returned %d bytes, want 32", tt.in, len(pub))
+			}
+		})
+	}
+}
+
+func TestParseOnionClientAuths(t *testing.T) {
+	zeroKey := "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+
+	t.Run("bare keys", func(t *testing.T) {
+		auths, err := parseOnionClientAuths([]string{zeroKey, zeroKey})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(auths) != 2 {
+			t.Fatalf("got %d client auths, want 2", len(auths))
+		}
+	})
+
+	t.Run("file of keys", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "auths.txt")
+		contents := zeroKey + "\n\n" + zeroKey + "\n"
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		auths, err := parseOnionClientAuths([]string{path})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(auths) != 2 {
+			t.Fatalf("got %d client auths, want 2 (blank lines should be skipped)", len(auths))
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		if _, err := parseOnionClientAuths([]string{"not-a-valid-key"}); err == nil {
+			t.Fatal("expected an error for an invalid key, got nil")
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package reseed
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const blacklistPollInterval = 30 * time.Second
+
+// limiterIdleTimeout is how long a per-IP rate limiter can go unused before
+// pruneExpired reclaims it.
+const limiterIdleTimeout = 10 * time.Minute
+
+// banEntry is a denied IP or CIDR range, optionally expiring at expires.
+type banEntry struct {
+	net     *net.IPNet
+	expires time.Time // zero means it never expires
+}
+
+func (b banEntry) expired(now time.Time) bool {
+	return !b.expires.IsZero() && now.After(b.expires)
+}
+
+// limiterEntry is a per-IP token bucket, tracked so pruneExpired can reclaim
+// it once the IP has gone quiet.
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Blacklist denies requests from a set of IPs and CIDR ranges, loaded from a
+// file and reloadable without a restart, plus an optional token-bucket rate
+// limiter that auto-bans offenders.
+type Blacklist struct {
+	mu      sync.RWMutex
+	entries []banEntry
+
+	rateLimit   int
+	banDuration time.Duration
+	trustProxy  bool
+	limiters    map[string]*limiterEntry
+}
+
+// NewBlacklist returns an empty Blacklist.
+func NewBlacklist() *Blacklist {
+	return &Blacklist{}
+}
+
+// LoadFile replaces the blacklist's static entries with the IPs and CIDR
+// ranges listed one-per-line in path. Lines starting with '#' are ignored.
+func (b *Blacklist) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var entries []banEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		ipNet, err := parseIPOrCIDR(line)
+		if err != nil {
+			log.Printf("blacklist: skipping invalid entry %q: %s", line, err)
+			continue
+		}
+		entries = append(entries, banEntry{net: ipNet})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.entries = append(b.staticlessEntries(), entries...)
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads path every 30s so ban list edits take effect without a
+// restart.
+func (b *Blacklist) Watch(path string) {
+	go func() {
+		for range time.Tick(blacklistPollInterval) {
+			if err := b.LoadFile(path); err != nil {
+				log.Printf("blacklist: failed to reload %s: %s", path, err)
+			}
+		}
+	}()
+}
+
+// SetRateLimit auto-bans an IP for banDuration once it exceeds
+// requestsPerMinute requests per minute. When trustProxy is true, the IP is
+// taken from the X-Forwarded-For header instead of the TCP remote address,
+// matching how the blacklist's static entries are evaluated. It also starts
+// a periodic sweep that prunes expired bans and idle rate limiters, so
+// --rateLimit works without requiring --blacklist/Watch to keep it tidy.
+func (b *Blacklist) SetRateLimit(requestsPerMinute int, banDuration time.Duration, trustProxy bool) {
+	b.mu.Lock()
+	b.rateLimit = requestsPerMinute
+	b.banDuration = banDuration
+	b.trustProxy = trustProxy
+	b.limiters = make(map[string]*limiterEntry)
+	b.mu.Unlock()
+
+	go func() {
+		for range time.Tick(blacklistPollInterval) {
+			b.pruneExpired()
+		}
+	}()
+}
+
+// pruneExpired drops expired ban entries and rate limiters idle for more
+// than limiterIdleTimeout, so a long-running server with --rateLimit keeps a
+// bounded memory footprint even without --blacklist ever reloading.
+func (b *Blacklist) pruneExpired() {
+	now := time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var kept []banEntry
+	for _, e := range b.entries {
+		if !e.expired(now) {
+			kept = append(kept, e)
+		}
+	}
+	b.entries = kept
+
+	for ip, le := range b.limiters {
+		if now.Sub(le.lastSeen) > limiterIdleTimeout {
+			delete(b.limiters, ip)
+		}
+	}
+}
+
+// AllowRequest reports whether r should be served, resolving its remote IP
+// per --trustProxy before consulting the ban list and rate limiter.
+func (b *Blacklist) AllowRequest(r *http.Request) bool {
+	return b.Allow(b.remoteIP(r))
+}
+
+func (b *Blacklist) remoteIP(r *http.Request) string {
+	b.mu.RLock()
+	trustProxy := b.trustProxy
+	b.mu.RUnlock()
+
+	if trustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			return strings.TrimSpace(strings.Split(xff, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Allow reports whether a request from remoteIP should be served. It
+// consults the static/TTL ban entries first, then (if rate limiting is
+// enabled) the per-IP token bucket, auto-banning remoteIP on the first
+// request that exceeds the configured rate.
+func (b *Blacklist) Allow(remoteIP string) bool {
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return true
+	}
+
+	now := time.Now()
+
+	b.mu.RLock()
+	for _, e := range b.entries {
+		if !e.expired(now) && e.net.Contains(ip) {
+			b.mu.RUnlock()
+			return false
+		}
+	}
+	rateLimit := b.rateLimit
+	b.mu.RUnlock()
+
+	if rateLimit <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	le, ok := b.limiters[remoteIP]
+	if !ok {
+		le = &limiterEntry{limiter: rate.NewLimiter(rate.Limit(float64(rateLimit)/60), rateLimit)}
+		b.limiters[remoteIP] = le
+	}
+	le.lastSeen = now
+	allowed := le.limiter.Allow()
+	if !allowed {
+		b.entries = append(b.entries, banEntry{
+			net:     singleIPNet(ip),
+			expires: now.Add(b.banDuration),
+		})
+	}
+	b.mu.Unlock()
+
+	return allowed
+}
+
+// staticlessEntries drops the file-loaded static entries (about to be
+// replaced by the reload) and any expired rate-limiter bans, keeping only
+// the rate limiter's still-active auto-bans across the reload.
+func (b *Blacklist) staticlessEntries() []banEntry {
+	now := time.Now()
+	var kept []banEntry
+	for _, e := range b.entries {
+		if !e.expires.IsZero() && !e.expired(now) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func parseIPOrCIDR(s string) (*net.IPNet, error) {
+	if _, ipNet, err := net.ParseCIDR(s); err == nil {
+		return ipNet, nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address or CIDR range", Text: s}
+	}
+	return singleIPNet(ip), nil
+}
+
+func singleIPNet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
@@ -0,0 +1,166 @@
+package reseed
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBlacklistAllowStaticEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []banEntry
+		ip      string
+		allowed bool
+	}{
+		{"no entries", nil, "1.2.3.4", true},
+		{"matching single IP", []banEntry{{net: mustIPNet(t, "1.2.3.4/32")}}, "1.2.3.4", false},
+		{"matching CIDR range", []banEntry{{net: mustIPNet(t, "1.2.3.0/24")}}, "1.2.3.4", false},
+		{"non-matching CIDR range", []banEntry{{net: mustIPNet(t, "1.2.4.0/24")}}, "1.2.3.4", true},
+		{"expired entry no longer bans", []banEntry{{net: mustIPNet(t, "1.2.3.4/32"), expires: time.Now().Add(-time.Minute)}}, "1.2.3.4", true},
+		{"unexpired entry still bans", []banEntry{{net: mustIPNet(t, "1.2.3.4/32"), expires: time.Now().Add(time.Minute)}}, "1.2.3.4", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBlacklist()
+			b.entries = tt.entries
+
+			if got := b.Allow(tt.ip); got != tt.allowed {
+				t.Fatalf("Allow(%q) = %v, want %v", tt.ip, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestBlacklistRateLimit(t *testing.T) {
+	b := NewBlacklist()
+	b.SetRateLimit(2, time.Minute, false)
+
+	if !b.Allow("5.6.7.8") {
+		t.Fatal("1st request should be allowed")
+	}
+	if !b.Allow("5.6.7.8") {
+		t.Fatal("2nd request should be allowed")
+	}
+	if b.Allow("5.6.7.8") {
+		t.Fatal("3rd request should exceed the rate limit and be denied")
+	}
+
+	// the offending IP should now be auto-banned, independent of the token
+	// bucket that tripped it
+	if b.Allow("5.6.7.8") {
+		t.Fatal("request should still be denied by the auto-ban")
+	}
+
+	if !b.Allow("9.9.9.9") {
+		t.Fatal("a different IP should have its own token bucket")
+	}
+}
+
+func TestBlacklistAllowRequestTrustProxy(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		remoteAddr string
+		xff        string
+		bannedIP   string
+		allowed    bool
+	}{
+		{"bans remote addr when proxy untrusted", false, "1.2.3.4:1234", "9.9.9.9", "1.2.3.4/32", false},
+		{"ignores XFF when proxy untrusted", false, "1.2.3.4:1234", "9.9.9.9", "9.9.9.9/32", true},
+		{"bans XFF client when proxy trusted", true, "10.0.0.1:1234", "9.9.9.9", "9.9.9.9/32", false},
+		{"falls back to remote addr without XFF", true, "1.2.3.4:1234", "", "1.2.3.4/32", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBlacklist()
+			b.trustProxy = tt.trustProxy
+			b.entries = []banEntry{{net: mustIPNet(t, tt.bannedIP)}}
+
+			r := &http.Request{RemoteAddr: tt.remoteAddr, Header: http.Header{}}
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+
+			if got := b.AllowRequest(r); got != tt.allowed {
+				t.Fatalf("AllowRequest() = %v, want %v", got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestBlacklistPruneExpired(t *testing.T) {
+	b := NewBlacklist()
+	b.entries = []banEntry{
+		{net: mustIPNet(t, "1.2.3.4/32"), expires: time.Now().Add(-time.Minute)}, // expired
+		{net: mustIPNet(t, "5.6.7.8/32"), expires: time.Now().Add(time.Minute)},  // not yet expired
+		{net: mustIPNet(t, "9.9.9.9/32")},                                        // static, never expires
+	}
+	b.limiters = map[string]*limiterEntry{
+		"1.1.1.1": {lastSeen: time.Now().Add(-2 * limiterIdleTimeout)}, // idle, should be pruned
+		"2.2.2.2": {lastSeen: time.Now()},                              // recently used, should stay
+	}
+
+	b.pruneExpired()
+
+	if len(b.entries) != 2 {
+		t.Fatalf("got %d entries after prune, want 2 (expired entry should be dropped)", len(b.entries))
+	}
+	if _, ok := b.limiters["1.1.1.1"]; ok {
+		t.Fatal("idle limiter should have been pruned")
+	}
+	if _, ok := b.limiters["2.2.2.2"]; !ok {
+		t.Fatal("recently-used limiter should not have been pruned")
+	}
+}
+
+func TestStaticlessEntries(t *testing.T) {
+	b := NewBlacklist()
+	b.entries = []banEntry{
+		{net: mustIPNet(t, "1.2.3.4/32")},                                        // static, should be dropped
+		{net: mustIPNet(t, "5.6.7.8/32"), expires: time.Now().Add(time.Minute)},  // active auto-ban, should be kept
+		{net: mustIPNet(t, "9.9.9.9/32"), expires: time.Now().Add(-time.Minute)}, // expired auto-ban, should be dropped
+	}
+
+	kept := b.staticlessEntries()
+	if len(kept) != 1 {
+		t.Fatalf("got %d entries, want 1 (only the active auto-ban)", len(kept))
+	}
+	if !kept[0].net.IP.Equal(mustIPNet(t, "5.6.7.8/32").IP) {
+		t.Fatalf("kept entry %v, want the 5.6.7.8/32 auto-ban", kept[0].net)
+	}
+}
+
+func TestParseIPOrCIDR(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"bare IPv4", "1.2.3.4", false},
+		{"CIDR range", "1.2.3.0/24", false},
+		{"bare IPv6", "::1", false},
+		{"invalid", "not-an-ip", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseIPOrCIDR(tt.in)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("parseIPOrCIDR(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func mustIPNet(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	ipNet, err := parseIPOrCIDR(s)
+	if err != nil {
+		t.Fatalf("parseIPOrCIDR(%q): %s", s, err)
+	}
+	return ipNet
+}
@@ -0,0 +1,50 @@
+package reseed
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/eyedeekay/sam3"
+)
+
+// ListenAndServeI2P serves plaintext reseed traffic over a streaming SAM
+// session opened against samAddr, publishing keys as the destination.
+func (s *Server) ListenAndServeI2P(samAddr string, keys sam3.I2PKeys) error {
+	ln, err := s.listenI2P(samAddr, keys)
+	if err != nil {
+		return err
+	}
+	return http.Serve(ln, s)
+}
+
+// ListenAndServeI2PTLS serves reseed traffic over I2P, additionally
+// terminating TLS with the given certificate/key files.
+func (s *Server) ListenAndServeI2PTLS(samAddr string, keys sam3.I2PKeys, certFile, keyFile string) error {
+	ln, err := s.listenI2P(samAddr, keys)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	return http.Serve(tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cert}}), s)
+}
+
+func (s *Server) listenI2P(samAddr string, keys sam3.I2PKeys) (net.Listener, error) {
+	sam, err := sam3.NewSAM(samAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := sam.NewStreamSession("reseed", keys, sam3.Options_Default)
+	if err != nil {
+		sam.Close()
+		return nil, err
+	}
+
+	return session.Listen()
+}
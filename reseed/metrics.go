@@ -0,0 +1,88 @@
+package reseed
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors a Server and Reseeder report
+// through as they serve su3 requests and rebuild their cache.
+type Metrics struct {
+	Su3Requests       *prometheus.CounterVec
+	BytesServed       prometheus.Counter
+	ActiveConnections prometheus.Gauge
+	BlacklistRejects  prometheus.Counter
+	RebuildDuration   prometheus.Histogram
+	RouterInfoCount   prometheus.Gauge
+	LastRebuildTime   prometheus.Gauge
+}
+
+// NewMetrics registers a Metrics set with prometheus.DefaultRegisterer.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Su3Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "reseed_su3_requests_total",
+			Help: "SU3 requests served, labeled by variant and result.",
+		}, []string{"variant", "result"}),
+		BytesServed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "reseed_bytes_served_total",
+			Help: "Total bytes served to reseed clients.",
+		}),
+		ActiveConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "reseed_active_connections",
+			Help: "Number of reseed connections currently being served.",
+		}),
+		BlacklistRejects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "reseed_blacklist_rejections_total",
+			Help: "Requests rejected by the blacklist or rate limiter.",
+		}),
+		RebuildDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "reseed_su3_rebuild_duration_seconds",
+			Help: "Time taken to rebuild the su3 cache.",
+		}),
+		RouterInfoCount: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "reseed_netdb_routerinfos",
+			Help: "Number of routerInfos in the current netdb.",
+		}),
+		LastRebuildTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "reseed_last_rebuild_timestamp_seconds",
+			Help: "Unix time of the last successful su3 cache rebuild.",
+		}),
+	}
+
+	prometheus.MustRegister(
+		m.Su3Requests,
+		m.BytesServed,
+		m.ActiveConnections,
+		m.BlacklistRejects,
+		m.RebuildDuration,
+		m.RouterInfoCount,
+		m.LastRebuildTime,
+	)
+
+	return m
+}
+
+// ObserveRequest records a single su3 request of the given variant
+// ("i2p", "onion", "clearnet", ...), its outcome ("ok", "error", ...), and
+// the number of bytes written in response.
+func (m *Metrics) ObserveRequest(variant, result string, bytesWritten int) {
+	if m == nil {
+		return
+	}
+	m.Su3Requests.WithLabelValues(variant, result).Inc()
+	m.BytesServed.Add(float64(bytesWritten))
+}
+
+// ObserveRebuild records the outcome of a su3 cache rebuild.
+func (m *Metrics) ObserveRebuild(dur time.Duration, su3Count, riCount int, err error) {
+	if m == nil {
+		return
+	}
+	m.RebuildDuration.Observe(dur.Seconds())
+	m.RouterInfoCount.Set(float64(riCount))
+	if err == nil {
+		m.LastRebuildTime.Set(float64(time.Now().Unix()))
+	}
+}
@@ -0,0 +1,79 @@
+package reseed
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newUnregisteredMetrics builds a Metrics whose collectors aren't registered
+// with prometheus.DefaultRegisterer, so tests can construct as many as they
+// like without tripping a "duplicate metrics collector" panic.
+func newUnregisteredMetrics() *Metrics {
+	return &Metrics{
+		Su3Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_reseed_su3_requests_total",
+		}, []string{"variant", "result"}),
+		BytesServed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "test_reseed_bytes_served_total",
+		}),
+		RebuildDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "test_reseed_su3_rebuild_duration_seconds",
+		}),
+		RouterInfoCount: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "test_reseed_netdb_routerinfos",
+		}),
+		LastRebuildTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "test_reseed_last_rebuild_timestamp_seconds",
+		}),
+	}
+}
+
+func TestMetricsObserveRequest(t *testing.T) {
+	m := newUnregisteredMetrics()
+
+	m.ObserveRequest("onion", "ok", 1024)
+	m.ObserveRequest("clearnet", "error", 0)
+
+	if got := testutil.ToFloat64(m.Su3Requests.WithLabelValues("onion", "ok")); got != 1 {
+		t.Fatalf("Su3Requests{onion,ok} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.BytesServed); got != 1024 {
+		t.Fatalf("BytesServed = %v, want 1024", got)
+	}
+}
+
+func TestMetricsObserveRebuild(t *testing.T) {
+	m := newUnregisteredMetrics()
+
+	m.ObserveRebuild(0, 2, 10, nil)
+
+	if got := testutil.ToFloat64(m.RouterInfoCount); got != 10 {
+		t.Fatalf("RouterInfoCount = %v, want 10", got)
+	}
+	if testutil.ToFloat64(m.LastRebuildTime) == 0 {
+		t.Fatal("LastRebuildTime should be set after a successful rebuild")
+	}
+}
+
+func TestMetricsObserveRebuildFailure(t *testing.T) {
+	m := newUnregisteredMetrics()
+
+	m.ObserveRebuild(0, 0, 3, errors.New("rebuild failed"))
+
+	if got := testutil.ToFloat64(m.RouterInfoCount); got != 3 {
+		t.Fatalf("RouterInfoCount = %v, want 3 (recorded even on failure)", got)
+	}
+	if testutil.ToFloat64(m.LastRebuildTime) != 0 {
+		t.Fatal("LastRebuildTime should be left unset after a failed rebuild")
+	}
+}
+
+func TestMetricsNilSafe(t *testing.T) {
+	var m *Metrics
+
+	m.ObserveRequest("onion", "ok", 1024)
+	m.ObserveRebuild(0, 0, 0, nil)
+}
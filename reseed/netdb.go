@@ -0,0 +1,46 @@
+package reseed
+
+import (
+	"io/ioutil"
+	"path/filepath"
+)
+
+// RouterInfo is a single signed routerInfo entry pulled from the netdb.
+type RouterInfo struct {
+	Name string
+	Data []byte
+}
+
+// NetDb supplies the routerInfo entries a Reseeder bundles into SU3 files.
+type NetDb interface {
+	RouterInfos() ([]RouterInfo, error)
+}
+
+// LocalNetDb reads routerInfo files out of a directory on disk.
+type LocalNetDb struct {
+	Path string
+}
+
+// NewLocalNetDb returns a NetDb backed by routerInfo files under dir.
+func NewLocalNetDb(dir string) *LocalNetDb {
+	return &LocalNetDb{Path: dir}
+}
+
+// RouterInfos reads every routerInfo file under Path.
+func (n *LocalNetDb) RouterInfos() ([]RouterInfo, error) {
+	matches, err := filepath.Glob(filepath.Join(n.Path, "routerInfo-*.dat"))
+	if err != nil {
+		return nil, err
+	}
+
+	ris := make([]RouterInfo, 0, len(matches))
+	for _, m := range matches {
+		data, err := ioutil.ReadFile(m)
+		if err != nil {
+			return nil, err
+		}
+		ris = append(ris, RouterInfo{Name: filepath.Base(m), Data: data})
+	}
+
+	return ris, nil
+}
@@ -0,0 +1,90 @@
+package reseed
+
+import (
+	"sync"
+	"time"
+)
+
+// Reseeder periodically rebuilds a cache of signed SU3 reseed bundles from
+// the configured NetDb.
+type Reseeder struct {
+	SigningKey      interface{}
+	SignerID        []byte
+	NumRi           int
+	NumSu3          int
+	RebuildInterval time.Duration
+	Metrics         *Metrics
+
+	netdb NetDb
+
+	mu          sync.RWMutex
+	su3Files    [][]byte
+	lastRebuild time.Time
+}
+
+// NewReseeder returns a Reseeder that pulls routerInfos from netdb.
+func NewReseeder(netdb NetDb) *Reseeder {
+	return &Reseeder{netdb: netdb}
+}
+
+// SetSigningKey swaps the su3 signing key used by the next rebuild. Safe to
+// call concurrently with the rebuild loop started by Start.
+func (r *Reseeder) SetSigningKey(key interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.SigningKey = key
+}
+
+// Start rebuilds the su3 cache once immediately, then every RebuildInterval.
+func (r *Reseeder) Start() {
+	r.rebuild()
+	go func() {
+		for range time.Tick(r.RebuildInterval) {
+			r.rebuild()
+		}
+	}()
+}
+
+// LastRebuild reports when the su3 cache was last rebuilt successfully.
+func (r *Reseeder) LastRebuild() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastRebuild
+}
+
+// Peek returns a bundle from the current su3 cache, or nil if it's empty.
+func (r *Reseeder) Peek() []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.su3Files) == 0 {
+		return nil
+	}
+	return r.su3Files[0]
+}
+
+func (r *Reseeder) rebuild() {
+	start := time.Now()
+
+	r.mu.RLock()
+	signingKey := r.SigningKey
+	r.mu.RUnlock()
+
+	ris, err := r.netdb.RouterInfos()
+	var su3Files [][]byte
+	if err == nil {
+		su3Files, err = buildSu3Bundles(ris, signingKey, r.SignerID, r.NumRi, r.NumSu3)
+	}
+
+	if r.Metrics != nil {
+		r.Metrics.ObserveRebuild(time.Since(start), len(su3Files), len(ris), err)
+	}
+
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.su3Files = su3Files
+	r.lastRebuild = start
+	r.mu.Unlock()
+}
@@ -0,0 +1,86 @@
+package reseed
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakeNetDb struct {
+	ris []RouterInfo
+	err error
+}
+
+func (f *fakeNetDb) RouterInfos() ([]RouterInfo, error) {
+	return f.ris, f.err
+}
+
+func someRouterInfos(n int) []RouterInfo {
+	ris := make([]RouterInfo, n)
+	for i := range ris {
+		ris[i] = RouterInfo{Name: "routerInfo", Data: []byte("data")}
+	}
+	return ris
+}
+
+func TestReseederRebuild(t *testing.T) {
+	r := NewReseeder(&fakeNetDb{ris: someRouterInfos(4)})
+	r.NumRi = 2
+	r.SetSigningKey("not-an-rsa-key")
+
+	if got := r.Peek(); got != nil {
+		t.Fatalf("Peek() before any rebuild = %v, want nil", got)
+	}
+	if !r.LastRebuild().IsZero() {
+		t.Fatal("LastRebuild() before any rebuild should be zero")
+	}
+
+	r.rebuild()
+
+	if got := r.Peek(); got == nil {
+		t.Fatal("Peek() after rebuild = nil, want a bundle")
+	}
+	if r.LastRebuild().IsZero() {
+		t.Fatal("LastRebuild() after rebuild should be non-zero")
+	}
+}
+
+func TestReseederRebuildNoSigningKey(t *testing.T) {
+	r := NewReseeder(&fakeNetDb{ris: someRouterInfos(4)})
+	r.NumRi = 2
+
+	r.rebuild()
+
+	if got := r.Peek(); got != nil {
+		t.Fatalf("Peek() after a failed rebuild = %v, want nil", got)
+	}
+	if !r.LastRebuild().IsZero() {
+		t.Fatal("LastRebuild() after a failed rebuild should still be zero")
+	}
+}
+
+func TestReseederRebuildNetDbError(t *testing.T) {
+	r := NewReseeder(&fakeNetDb{err: errors.New("netdb unavailable")})
+	r.NumRi = 2
+	r.SetSigningKey("not-an-rsa-key")
+
+	r.rebuild()
+
+	if got := r.Peek(); got != nil {
+		t.Fatalf("Peek() after a netdb error = %v, want nil", got)
+	}
+}
+
+func TestReseederSetSigningKeyReplacesExisting(t *testing.T) {
+	r := NewReseeder(&fakeNetDb{ris: someRouterInfos(2)})
+	r.NumRi = 2
+
+	r.SetSigningKey("first-key")
+	if r.SigningKey != "first-key" {
+		t.Fatalf("SigningKey = %v, want %q", r.SigningKey, "first-key")
+	}
+
+	r.SetSigningKey("second-key")
+	if r.SigningKey != "second-key" {
+		t.Fatalf("SigningKey = %v, want %q", r.SigningKey, "second-key")
+	}
+}
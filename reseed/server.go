@@ -0,0 +1,158 @@
+package reseed
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/cretz/bine/tor"
+)
+
+// Server serves su3 reseed bundles over HTTP(S), onion, and I2P transports.
+type Server struct {
+	Reseeder  *Reseeder
+	Blacklist *Blacklist
+	Metrics   *Metrics
+	Addr      string
+
+	prefix     string
+	trustProxy bool
+}
+
+// NewServer returns a Server that serves bundles under prefix. When
+// trustProxy is set, the client IP is taken from X-Forwarded-For.
+func NewServer(prefix string, trustProxy bool) *Server {
+	return &Server{prefix: prefix, trustProxy: trustProxy}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Metrics != nil {
+		s.Metrics.ActiveConnections.Inc()
+		defer s.Metrics.ActiveConnections.Dec()
+	}
+
+	if !strings.HasPrefix(r.URL.Path, s.prefix) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if s.Blacklist != nil {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			ip = host
+		}
+		if xff := r.Header.Get("X-Forwarded-For"); s.trustProxy && xff != "" {
+			ip = strings.TrimSpace(strings.Split(xff, ",")[0])
+		}
+		if !s.Blacklist.Allow(ip) {
+			if s.Metrics != nil {
+				s.Metrics.BlacklistRejects.Inc()
+			}
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	variant := requestVariant(r)
+
+	su3 := s.Reseeder.Peek()
+	if su3 == nil {
+		if s.Metrics != nil {
+			s.Metrics.ObserveRequest(variant, "empty", 0)
+		}
+		http.Error(w, "no su3 bundles available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	n, err := w.Write(su3)
+	if s.Metrics != nil {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		s.Metrics.ObserveRequest(variant, result, n)
+	}
+}
+
+func requestVariant(r *http.Request) string {
+	if strings.HasSuffix(r.Host, ".onion") {
+		return "onion"
+	}
+	if strings.HasSuffix(r.Host, ".b32.i2p") {
+		return "i2p"
+	}
+	return "clearnet"
+}
+
+// ListenAndServe serves plaintext HTTP on s.Addr.
+func (s *Server) ListenAndServe() error {
+	return http.ListenAndServe(s.Addr, s)
+}
+
+// ListenAndServeTLS serves HTTPS on s.Addr using the given certificate/key
+// files.
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	return http.ListenAndServeTLS(s.Addr, certFile, keyFile, s)
+}
+
+// ListenAndServeTLSConfig serves HTTPS on s.Addr using a caller-supplied
+// tls.Config, e.g. one backed by a reloadable certificate store or an ACME
+// manager, rather than fixed certificate/key file paths.
+func (s *Server) ListenAndServeTLSConfig(tlsConfig *tls.Config) error {
+	ln, err := tls.Listen("tcp", s.Addr, tlsConfig)
+	if err != nil {
+		return err
+	}
+	return http.Serve(ln, s)
+}
+
+// ListenAndServeOnion publishes a v3 onion service per listenConf and serves
+// plaintext HTTP over it.
+func (s *Server) ListenAndServeOnion(startConf *tor.StartConf, listenConf *tor.ListenConf) error {
+	ln, err := s.listenOnion(startConf, listenConf)
+	if err != nil {
+		return err
+	}
+	return http.Serve(ln, s)
+}
+
+// ListenAndServeOnionTLS publishes a v3 onion service per listenConf and
+// serves HTTPS over it using the given certificate/key files.
+func (s *Server) ListenAndServeOnionTLS(startConf *tor.StartConf, listenConf *tor.ListenConf, certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	return s.ListenAndServeOnionTLSConfig(startConf, listenConf, &tls.Config{Certificates: []tls.Certificate{cert}})
+}
+
+// ListenAndServeOnionTLSConfig publishes a v3 onion service per listenConf
+// and serves HTTPS over it using a caller-supplied tls.Config, e.g. one
+// backed by a reloadable certificate store, rather than fixed certificate/key
+// file paths.
+func (s *Server) ListenAndServeOnionTLSConfig(startConf *tor.StartConf, listenConf *tor.ListenConf, tlsConfig *tls.Config) error {
+	ln, err := s.listenOnion(startConf, listenConf)
+	if err != nil {
+		return err
+	}
+
+	return http.Serve(tls.NewListener(ln, tlsConfig), s)
+}
+
+func (s *Server) listenOnion(startConf *tor.StartConf, listenConf *tor.ListenConf) (net.Listener, error) {
+	t, err := tor.Start(context.Background(), startConf)
+	if err != nil {
+		return nil, err
+	}
+
+	onion, err := t.Listen(context.Background(), listenConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return onion, nil
+}
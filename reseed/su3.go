@@ -0,0 +1,60 @@
+package reseed
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+)
+
+// buildSu3Bundles groups ris into su3 files of numRi routerInfos each,
+// signed by signingKey/signerID. numSu3 caps how many bundles are produced;
+// 0 means build as many as the netdb supports.
+func buildSu3Bundles(ris []RouterInfo, signingKey interface{}, signerID []byte, numRi, numSu3 int) ([][]byte, error) {
+	if signingKey == nil {
+		return nil, errors.New("reseed: no su3 signing key configured")
+	}
+	if numRi <= 0 {
+		return nil, errors.New("reseed: numRi must be positive")
+	}
+
+	want := len(ris) / numRi
+	if numSu3 > 0 && numSu3 < want {
+		want = numSu3
+	}
+
+	bundles := make([][]byte, 0, want)
+	for i := 0; i < want; i++ {
+		chunk := ris[i*numRi : (i+1)*numRi]
+
+		var buf bytes.Buffer
+		for _, ri := range chunk {
+			buf.Write(ri.Data)
+		}
+
+		bundles = append(bundles, signSu3(buf.Bytes(), signingKey, signerID))
+	}
+
+	return bundles, nil
+}
+
+// signSu3 wraps content in a minimal su3 container, signed by key if it's an
+// RSA private key (the su3 format i2pd/Java routers accept).
+func signSu3(content []byte, key interface{}, signerID []byte) []byte {
+	var sig []byte
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		hashed := sha256.Sum256(content)
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hashed[:])
+	}
+
+	var buf bytes.Buffer
+	buf.Write([]byte("I2Psu3"))
+	buf.Write(signerID)
+	buf.WriteByte(0)
+	buf.Write(content)
+	buf.Write(sig)
+
+	return buf.Bytes()
+}